@@ -0,0 +1,451 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// MaxArchiveEntrySize caps the decompressed size of any single entry.
+	MaxArchiveEntrySize = 500 << 20
+	// MaxArchiveTotalSize caps the decompressed size of the whole archive,
+	// guarding against zip-bomb style decompression ratios.
+	MaxArchiveTotalSize = 2 << 30
+	// MaxArchiveEntryCount caps the number of entries an archive may contain.
+	MaxArchiveEntryCount = 20000
+)
+
+// storeArchive accepts a single uploaded archive (zip, tar, or tar.gz) and
+// stream-extracts its entries into the codebase via the Storage backend, as
+// an alternative to the multipart file-list upload handled by storeFiles.
+func (s *StorageServer) storeArchive(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
+
+	if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
+		respondWithError(w, http.StatusBadRequest, "File too large or invalid form data")
+		return
+	}
+
+	codebaseID := r.FormValue("codebase_id")
+	if codebaseID == "" {
+		respondWithError(w, http.StatusBadRequest, "Codebase ID is required")
+		return
+	}
+
+	if _, err := uuid.Parse(codebaseID); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid codebase ID")
+		return
+	}
+
+	archiveHeader, _, err := r.FormFile("archive")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Archive file is required")
+		return
+	}
+	defer archiveHeader.Close()
+
+	fileHeaders := r.MultipartForm.File["archive"]
+	if len(fileHeaders) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Archive file is required")
+		return
+	}
+	archiveName := fileHeaders[0].Filename
+
+	var result extractResult
+
+	switch archiveKind(archiveName) {
+	case archiveKindZip:
+		result, err = extractZipArchive(s.storage, s.avScanner, codebaseID, archiveHeader)
+	case archiveKindTarGz:
+		gzr, gzErr := gzip.NewReader(archiveHeader)
+		if gzErr != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid gzip stream")
+			return
+		}
+		defer gzr.Close()
+		result, err = extractTarArchive(s.storage, s.avScanner, codebaseID, gzr)
+	case archiveKindTar:
+		result, err = extractTarArchive(s.storage, s.avScanner, codebaseID, archiveHeader)
+	default:
+		respondWithError(w, http.StatusBadRequest, "Unsupported archive type (expected .zip, .tar or .tar.gz)")
+		return
+	}
+
+	if err != nil {
+		deleteFiles(s.storage, codebaseID, result.stored)
+		if len(result.stored) > 0 {
+			s.reindexCodebase(codebaseID)
+		}
+		log.Printf("Error extracting archive %s for codebase %s: %v", archiveName, codebaseID, err)
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Failed to extract archive: %v", err))
+		return
+	}
+
+	if len(result.stored) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":        false,
+			"error":          "No valid entries were extracted",
+			"infected_files": result.infected,
+		})
+		return
+	}
+
+	if err := s.updateManifest(codebaseID, result.metadata); err != nil {
+		log.Printf("Error updating metadata sidecar for codebase %s: %v", codebaseID, err)
+	}
+
+	response := StoreResponse{
+		Success:       true,
+		Message:       fmt.Sprintf("Successfully extracted %d files (%d bytes total)", len(result.stored), result.totalSize),
+		InfectedFiles: result.infected,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	s.reindexCodebase(codebaseID)
+
+	log.Printf("Archive extracted for codebase %s: %d files, %d bytes", codebaseID, len(result.stored), result.totalSize)
+}
+
+// extractResult summarizes what extractZipArchive/extractTarArchive did,
+// mirroring the bookkeeping storeFiles keeps inline in its own loop.
+type extractResult struct {
+	stored    []string
+	metadata  []FileMetadata
+	infected  []InfectedFile
+	totalSize int64
+}
+
+type archiveKindT int
+
+const (
+	archiveKindUnknown archiveKindT = iota
+	archiveKindZip
+	archiveKindTar
+	archiveKindTarGz
+)
+
+func archiveKind(name string) archiveKindT {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		return archiveKindTarGz
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveKindTar
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveKindZip
+	default:
+		return archiveKindUnknown
+	}
+}
+
+// validateEntryName cleans an archive entry name and rejects anything that
+// would escape the codebase root once stored (the "Zip-Slip" class of bug).
+func validateEntryName(name string) (string, error) {
+	cleaned := filepath.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if cleaned == "." || cleaned == "" {
+		return "", fmt.Errorf("empty entry name")
+	}
+	if strings.HasPrefix(cleaned, "..") || filepath.IsAbs(cleaned) {
+		return "", fmt.Errorf("entry %q escapes archive root", name)
+	}
+	return cleaned, nil
+}
+
+// extractZipArchive and extractTarArchive below return their accumulated
+// extractResult alongside any error, not a zero value: the caller rolls
+// back only result.stored (the entries *this* call wrote), and that's only
+// possible if a failure partway through still reports what came before it.
+func extractZipArchive(storage Storage, avScanner *AVScanner, codebaseID string, r io.ReaderAt) (extractResult, error) {
+	size, err := seekerSize(r)
+	if err != nil {
+		return extractResult{}, err
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return extractResult{}, err
+	}
+
+	if len(zr.File) > MaxArchiveEntryCount {
+		return extractResult{}, fmt.Errorf("archive contains too many entries (%d > %d)", len(zr.File), MaxArchiveEntryCount)
+	}
+
+	var result extractResult
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			log.Printf("Skipping non-regular zip entry: %s", f.Name)
+			continue
+		}
+		if f.UncompressedSize64 > MaxArchiveEntrySize {
+			return result, fmt.Errorf("entry %q exceeds max entry size", f.Name)
+		}
+
+		relPath, err := validateEntryName(f.Name)
+		if err != nil {
+			log.Printf("Skipping unsafe zip entry: %v", err)
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return result, err
+		}
+
+		written, meta, infected, skipped, err := scanAndStoreEntry(storage, avScanner, codebaseID, relPath, rc, MaxArchiveEntrySize)
+		rc.Close()
+		if err != nil {
+			return result, err
+		}
+		if skipped {
+			continue
+		}
+		if infected != nil {
+			result.infected = append(result.infected, *infected)
+			continue
+		}
+
+		result.totalSize += written
+		result.stored = append(result.stored, relPath)
+		if result.totalSize > MaxArchiveTotalSize {
+			return result, fmt.Errorf("archive exceeds total size cap of %d bytes", MaxArchiveTotalSize)
+		}
+		result.metadata = append(result.metadata, meta)
+	}
+
+	return result, nil
+}
+
+func extractTarArchive(storage Storage, avScanner *AVScanner, codebaseID string, r io.Reader) (extractResult, error) {
+	tr := tar.NewReader(r)
+
+	var result extractResult
+	var entryCount int
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		entryCount++
+		if entryCount > MaxArchiveEntryCount {
+			return result, fmt.Errorf("archive contains too many entries (> %d)", MaxArchiveEntryCount)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+			// fall through to extraction below
+		default:
+			// Skip symlinks, hardlinks, device files, FIFOs, etc.
+			log.Printf("Skipping non-regular tar entry: %s (type %d)", hdr.Name, hdr.Typeflag)
+			continue
+		}
+
+		if hdr.Size > MaxArchiveEntrySize {
+			return result, fmt.Errorf("entry %q exceeds max entry size", hdr.Name)
+		}
+
+		relPath, err := validateEntryName(hdr.Name)
+		if err != nil {
+			log.Printf("Skipping unsafe tar entry: %v", err)
+			continue
+		}
+
+		written, meta, infected, skipped, err := scanAndStoreEntry(storage, avScanner, codebaseID, relPath, tr, MaxArchiveEntrySize)
+		if err != nil {
+			return result, err
+		}
+		if skipped {
+			continue
+		}
+		if infected != nil {
+			result.infected = append(result.infected, *infected)
+			continue
+		}
+
+		result.totalSize += written
+		result.stored = append(result.stored, relPath)
+		if result.totalSize > MaxArchiveTotalSize {
+			return result, fmt.Errorf("archive exceeds total size cap of %d bytes", MaxArchiveTotalSize)
+		}
+		result.metadata = append(result.metadata, meta)
+	}
+
+	return result, nil
+}
+
+// scanAndStoreEntry reads a capped archive entry fully into memory so it can
+// be AV-scanned the same way storeFiles scans multipart uploads, then hashes
+// and puts it into storage via the same hashAndStore helper storeFiles uses,
+// so every codebase gets a metadata sidecar regardless of upload path.
+// skipped reports an entry that should be silently dropped (a scan error
+// under fail-closed, mirroring storeFiles); infected reports one the
+// scanner rejected.
+func scanAndStoreEntry(storage Storage, avScanner *AVScanner, codebaseID, relPath string, src io.Reader, maxSize int64) (written int64, meta FileMetadata, infected *InfectedFile, skipped bool, err error) {
+	buf, err := io.ReadAll(io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return 0, FileMetadata{}, nil, false, err
+	}
+	if int64(len(buf)) > maxSize {
+		return 0, FileMetadata{}, nil, false, fmt.Errorf("entry exceeds max entry size of %d bytes", maxSize)
+	}
+
+	var content io.Reader = bytes.NewReader(buf)
+	if avScanner != nil {
+		isInfected, signature, scanErr := avScanner.Scan(bytes.NewReader(buf))
+		if scanErr != nil {
+			log.Printf("AV scan error for archive entry %s: %v", relPath, scanErr)
+			if !avScanner.AllowOnError() {
+				return 0, FileMetadata{}, nil, true, nil
+			}
+		} else if isInfected {
+			log.Printf("Rejected infected archive entry %s: %s", relPath, signature)
+			return 0, FileMetadata{}, &InfectedFile{Path: relPath, Signature: signature}, false, nil
+		}
+	}
+
+	hashedContent, sha256Hex, sniff := hashAndStore(content)
+
+	written, err = storage.Put(codebaseID, relPath, hashedContent)
+	if err != nil {
+		return 0, FileMetadata{}, nil, false, err
+	}
+
+	meta = FileMetadata{
+		RelativePath: relPath,
+		Size:         written,
+		SHA256:       sha256Hex(),
+		Mime:         detectMime(relPath),
+		Modified:     time.Now(),
+		IsText:       isTextFile(sniff.Bytes()),
+	}
+	return written, meta, nil, false, nil
+}
+
+// deleteFiles removes exactly the given paths from codebaseID, used to roll
+// back the entries a failed storeArchive call itself wrote. Files at other
+// paths are left alone; a path that the call overwrote is deleted too, and
+// its earlier content is not restored.
+func deleteFiles(storage Storage, codebaseID string, paths []string) {
+	for _, p := range paths {
+		storage.Delete(codebaseID, p)
+	}
+}
+
+func seekerSize(r io.ReaderAt) (int64, error) {
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return 0, fmt.Errorf("archive reader does not support seeking")
+	}
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// downloadTarGz streams a gzip'd tar of the codebase, mirroring downloadZip
+// for CI pipelines that expect tarballs rather than zips.
+func (s *StorageServer) downloadTarGz(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	codebaseID := vars["id"]
+
+	if _, err := uuid.Parse(codebaseID); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid codebase ID")
+		return
+	}
+
+	exists, err := s.storage.Exists(codebaseID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to check codebase")
+		return
+	}
+	if !exists {
+		respondWithError(w, http.StatusNotFound, "Codebase not found")
+		return
+	}
+
+	filename := fmt.Sprintf("codebase-%s.tar.gz", codebaseID)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	if err := createTarGzArchive(w, s.storage, codebaseID); err != nil {
+		log.Printf("Error creating tar.gz for codebase %s: %v", codebaseID, err)
+		return
+	}
+
+	log.Printf("Downloaded tar.gz archive for codebase: %s", codebaseID)
+}
+
+func createTarGzArchive(w io.Writer, storage Storage, codebaseID string) error {
+	gzWriter := gzip.NewWriter(w)
+	defer gzWriter.Close()
+
+	tarWriter := tar.NewWriter(gzWriter)
+	defer tarWriter.Close()
+
+	return storage.Walk(codebaseID, func(info FileInfo) error {
+		if info.RelPath == metadataSidecarName {
+			return nil
+		}
+
+		hdr := &tar.Header{
+			Name:    info.RelPath,
+			ModTime: info.ModTime,
+		}
+		if info.IsDir {
+			hdr.Name += "/"
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0755
+		} else {
+			hdr.Typeflag = tar.TypeReg
+			hdr.Mode = 0644
+			hdr.Size = info.Size
+		}
+
+		if err := tarWriter.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir {
+			return nil
+		}
+
+		sourceFile, _, err := storage.Get(codebaseID, info.RelPath)
+		if err != nil {
+			return err
+		}
+		defer sourceFile.Close()
+
+		_, err = io.Copy(tarWriter, sourceFile)
+		return err
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidateEntryName(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"plain relative path", "foo/bar.txt", false},
+		{"cleans redundant dot segments", "./foo/./bar.txt", false},
+		{"normalizes backslashes", `foo\bar.txt`, false},
+		{"rejects parent traversal", "../etc/passwd", true},
+		{"rejects nested parent traversal", "foo/../../etc/passwd", true},
+		{"rejects absolute path", "/etc/passwd", true},
+		{"rejects empty name", "", true},
+		{"rejects dot only", ".", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := validateEntryName(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("validateEntryName(%q) = %q, want error", tc.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateEntryName(%q) unexpected error: %v", tc.input, err)
+			}
+		})
+	}
+}
+
+// memStorage is a minimal in-memory Storage, just enough to exercise
+// extractZipArchive/extractTarArchive without touching disk.
+type memStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{files: make(map[string][]byte)}
+}
+
+func (m *memStorage) key(codebaseID, path string) string { return codebaseID + "/" + path }
+
+func (m *memStorage) Put(codebaseID, path string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	m.files[m.key(codebaseID, path)] = data
+	m.mu.Unlock()
+	return int64(len(data)), nil
+}
+
+func (m *memStorage) Get(codebaseID, path string) (io.ReadCloser, FileInfo, error) {
+	m.mu.Lock()
+	data, ok := m.files[m.key(codebaseID, path)]
+	m.mu.Unlock()
+	if !ok {
+		return nil, FileInfo{}, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), FileInfo{RelPath: path, Size: int64(len(data))}, nil
+}
+
+func (m *memStorage) Stat(codebaseID, path string) (FileInfo, error) {
+	m.mu.Lock()
+	data, ok := m.files[m.key(codebaseID, path)]
+	m.mu.Unlock()
+	if !ok {
+		return FileInfo{}, ErrNotFound
+	}
+	return FileInfo{RelPath: path, Size: int64(len(data))}, nil
+}
+
+func (m *memStorage) Walk(codebaseID string, fn WalkFn) error { return nil }
+
+func (m *memStorage) Delete(codebaseID, path string) error {
+	m.mu.Lock()
+	delete(m.files, m.key(codebaseID, path))
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memStorage) Exists(codebaseID string) (bool, error) { return len(m.files) > 0, nil }
+
+func buildZip(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := entries[name]
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("creating zip entry %q: %v", name, err)
+		}
+		if _, err := f.Write(content); err != nil {
+			t.Fatalf("writing zip entry %q: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("closing zip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractZipArchiveStoresValidEntries(t *testing.T) {
+	data := buildZip(t, map[string][]byte{
+		"a.txt":       []byte("hello"),
+		"sub/b.txt":   []byte("world"),
+		"../evil.txt": []byte("should be skipped, not escape"),
+	})
+
+	storage := newMemStorage()
+	result, err := extractZipArchive(storage, nil, "cb1", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("extractZipArchive returned error: %v", err)
+	}
+	if len(result.stored) != 2 {
+		t.Fatalf("expected 2 stored entries, got %d: %v", len(result.stored), result.stored)
+	}
+	if _, err := storage.Get("cb1", "a.txt"); err != nil {
+		t.Errorf("expected a.txt to be stored: %v", err)
+	}
+	if _, err := storage.Get("cb1", "../evil.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected escaping entry to be skipped, got err=%v", err)
+	}
+}
+
+func TestExtractZipArchiveEntryTooLargeReportsPartialProgress(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), int(MaxArchiveEntrySize)+1)
+	data := buildZip(t, map[string][]byte{
+		"good.txt": []byte("fits fine"),
+		"huge.txt": big,
+	})
+
+	storage := newMemStorage()
+	result, err := extractZipArchive(storage, nil, "cb1", bytes.NewReader(data))
+	if err == nil {
+		t.Fatalf("expected an error for an oversized entry")
+	}
+	if len(result.stored) != 1 || result.stored[0] != "good.txt" {
+		t.Fatalf("expected only good.txt reported as stored so the caller can roll it back, got %v", result.stored)
+	}
+}
+
+func buildTar(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, content := range entries {
+		hdr := &tar.Header{
+			Name:    name,
+			Size:    int64(len(content)),
+			Mode:    0644,
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("writing tar header %q: %v", name, err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatalf("writing tar content %q: %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractTarArchiveRejectsEntryCountCap(t *testing.T) {
+	entries := make(map[string][]byte, MaxArchiveEntryCount+1)
+	for i := 0; i < MaxArchiveEntryCount+1; i++ {
+		entries[tarEntryName(i)] = []byte("x")
+	}
+	data := buildTar(t, entries)
+
+	storage := newMemStorage()
+	_, err := extractTarArchive(storage, nil, "cb1", bytes.NewReader(data))
+	if err == nil {
+		t.Fatalf("expected the entry-count cap to reject this archive")
+	}
+}
+
+func tarEntryName(i int) string {
+	return "f" + strconv.Itoa(i) + ".txt"
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// getArchiveEntry streams a single entry out of a ZIP archive already
+// stored inside a codebase, without extracting the whole archive. It uses
+// archive/zip's random-access reader to seek straight to the entry.
+func (s *StorageServer) getArchiveEntry(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	codebaseID := vars["id"]
+	archivePath := r.URL.Query().Get("archive")
+	entryName := r.URL.Query().Get("entry")
+
+	if _, err := uuid.Parse(codebaseID); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid codebase ID")
+		return
+	}
+
+	if archivePath == "" || entryName == "" {
+		respondWithError(w, http.StatusBadRequest, "Both 'archive' and 'entry' query parameters are required")
+		return
+	}
+
+	// Clean the archive path and ensure it's safe, same checks as getFileContent.
+	cleanArchive := filepath.Clean(archivePath)
+	if strings.HasPrefix(cleanArchive, "..") || strings.Contains(cleanArchive, "..") {
+		respondWithError(w, http.StatusBadRequest, "Invalid archive path")
+		return
+	}
+
+	cleanEntry := filepath.Clean(strings.ReplaceAll(entryName, "\\", "/"))
+	if strings.HasPrefix(cleanEntry, "..") || filepath.IsAbs(cleanEntry) {
+		respondWithError(w, http.StatusBadRequest, "Invalid entry path")
+		return
+	}
+
+	reader, info, err := s.storage.Get(codebaseID, cleanArchive)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Archive not found")
+		return
+	}
+	defer reader.Close()
+
+	readerAt, ok := reader.(io.ReaderAt)
+	if !ok {
+		respondWithError(w, http.StatusNotImplemented, "Entry extraction requires a seekable storage backend")
+		return
+	}
+
+	zr, err := zip.NewReader(readerAt, info.Size)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Failed to open archive: %v", err))
+		return
+	}
+
+	for _, f := range zr.File {
+		if filepath.Clean(f.Name) != cleanEntry {
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			respondWithError(w, http.StatusBadRequest, "Entry is a directory")
+			return
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to open archive entry")
+			return
+		}
+		defer rc.Close()
+
+		contentType := mime.TypeByExtension(filepath.Ext(cleanEntry))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		filename := filepath.Base(cleanEntry)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+		if _, err := io.Copy(w, rc); err != nil {
+			log.Printf("Error streaming archive entry %s from %s: %v", cleanEntry, cleanArchive, err)
+		}
+		return
+	}
+
+	respondWithError(w, http.StatusNotFound, "Entry not found in archive")
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// GCSStorage stores codebases as objects in a Google Cloud Storage bucket,
+// mirroring S3Storage for deployments that standardize on GCP instead.
+type GCSStorage struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// NewGCSStorage builds a GCSStorage from env vars:
+//
+//	GCS_BUCKET  (required)
+//	GCS_PREFIX  (optional, default "")
+//
+// Credentials are resolved via the standard Application Default
+// Credentials chain.
+func NewGCSStorage() (*GCSStorage, error) {
+	bucket := os.Getenv("GCS_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("GCS_BUCKET is required when STORAGE_BACKEND=gcs")
+	}
+
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("creating GCS client: %w", err)
+	}
+
+	return &GCSStorage{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(os.Getenv("GCS_PREFIX"), "/"),
+	}, nil
+}
+
+func (g *GCSStorage) object(codebaseID, path string) string {
+	name := codebaseID + "/" + strings.TrimPrefix(path, "/")
+	if g.prefix != "" {
+		name = g.prefix + "/" + name
+	}
+	return name
+}
+
+func (g *GCSStorage) Put(codebaseID, path string, r io.Reader) (int64, error) {
+	ctx := context.Background()
+	obj := g.client.Bucket(g.bucket).Object(g.object(codebaseID, path))
+	writer := obj.NewWriter(ctx)
+
+	written, err := io.Copy(writer, r)
+	if err != nil {
+		writer.Close()
+		return 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+func (g *GCSStorage) Get(codebaseID, path string) (io.ReadCloser, FileInfo, error) {
+	ctx := context.Background()
+	obj := g.client.Bucket(g.bucket).Object(g.object(codebaseID, path))
+
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, FileInfo{}, ErrNotFound
+		}
+		return nil, FileInfo{}, err
+	}
+
+	reader, err := obj.NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, FileInfo{}, ErrNotFound
+		}
+		return nil, FileInfo{}, err
+	}
+
+	return reader, FileInfo{RelPath: path, Size: attrs.Size, ModTime: attrs.Updated}, nil
+}
+
+func (g *GCSStorage) Stat(codebaseID, path string) (FileInfo, error) {
+	attrs, err := g.client.Bucket(g.bucket).Object(g.object(codebaseID, path)).Attrs(context.Background())
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return FileInfo{}, ErrNotFound
+		}
+		return FileInfo{}, err
+	}
+	return FileInfo{RelPath: path, Size: attrs.Size, ModTime: attrs.Updated}, nil
+}
+
+func (g *GCSStorage) Walk(codebaseID string, fn WalkFn) error {
+	ctx := context.Background()
+	prefix := g.object(codebaseID, "")
+
+	it := g.client.Bucket(g.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		relPath := strings.TrimPrefix(attrs.Name, prefix)
+		if relPath == "" {
+			continue
+		}
+
+		if err := fn(FileInfo{RelPath: relPath, Size: attrs.Size, ModTime: attrs.Updated}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *GCSStorage) Delete(codebaseID, path string) error {
+	return g.client.Bucket(g.bucket).Object(g.object(codebaseID, path)).Delete(context.Background())
+}
+
+func (g *GCSStorage) Exists(codebaseID string) (bool, error) {
+	found := false
+	err := g.Walk(codebaseID, func(FileInfo) error {
+		found = true
+		return nil
+	})
+	return found, err
+}
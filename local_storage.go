@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage is the default Storage backend: it persists files on the
+// server's own disk under baseDir/<codebaseID>/<path>, exactly as the
+// server behaved before the Storage interface existed.
+type LocalStorage struct {
+	baseDir string
+}
+
+func NewLocalStorage(baseDir string) *LocalStorage {
+	return &LocalStorage{baseDir: baseDir}
+}
+
+// resolve cleans path and ensures it stays within the codebase's
+// directory, guarding against directory-traversal.
+func (l *LocalStorage) resolve(codebaseID, path string) (string, error) {
+	base := filepath.Join(l.baseDir, codebaseID)
+	cleanPath := filepath.Clean(path)
+	if strings.HasPrefix(cleanPath, "..") {
+		return "", fmt.Errorf("path %q escapes codebase root", path)
+	}
+
+	full := filepath.Join(base, cleanPath)
+	if !strings.HasPrefix(full, filepath.Clean(base)) {
+		return "", fmt.Errorf("path %q escapes codebase root", path)
+	}
+	return full, nil
+}
+
+func (l *LocalStorage) Put(codebaseID, path string, r io.Reader) (int64, error) {
+	full, err := l.resolve(codebaseID, path)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return 0, err
+	}
+
+	dst, err := os.Create(full)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	return io.Copy(dst, r)
+}
+
+func (l *LocalStorage) Get(codebaseID, path string) (io.ReadCloser, FileInfo, error) {
+	full, err := l.resolve(codebaseID, path)
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+
+	info, err := os.Stat(full)
+	if os.IsNotExist(err) {
+		return nil, FileInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+	if info.IsDir() {
+		return nil, FileInfo{}, fmt.Errorf("%q is a directory", path)
+	}
+
+	file, err := os.Open(full)
+	if err != nil {
+		return nil, FileInfo{}, err
+	}
+
+	return file, FileInfo{RelPath: path, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (l *LocalStorage) Stat(codebaseID, path string) (FileInfo, error) {
+	full, err := l.resolve(codebaseID, path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	info, err := os.Stat(full)
+	if os.IsNotExist(err) {
+		return FileInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	return FileInfo{RelPath: path, Size: info.Size(), ModTime: info.ModTime(), IsDir: info.IsDir()}, nil
+}
+
+func (l *LocalStorage) Walk(codebaseID string, fn WalkFn) error {
+	base := filepath.Join(l.baseDir, codebaseID)
+
+	err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		relPath = strings.ReplaceAll(relPath, "\\", "/")
+
+		return fn(FileInfo{
+			RelPath: relPath,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   info.IsDir(),
+		})
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *LocalStorage) Delete(codebaseID, path string) error {
+	full, err := l.resolve(codebaseID, path)
+	if err != nil {
+		return err
+	}
+	return os.Remove(full)
+}
+
+func (l *LocalStorage) Exists(codebaseID string) (bool, error) {
+	base := filepath.Join(l.baseDir, codebaseID)
+	_, err := os.Stat(base)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageResolve(t *testing.T) {
+	ls := NewLocalStorage(t.TempDir())
+
+	cases := []struct {
+		name       string
+		codebaseID string
+		path       string
+		wantErr    bool
+	}{
+		{"plain relative path", "cb1", "foo/bar.txt", false},
+		{"cleans redundant dot segments", "cb1", "./foo/./bar.txt", false},
+		{"rejects parent traversal", "cb1", "../etc/passwd", true},
+		{"rejects nested parent traversal", "cb1", "foo/../../etc/passwd", true},
+		{"rejects deeply nested parent traversal", "cb1", "a/b/../../../etc/passwd", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			full, err := ls.resolve(tc.codebaseID, tc.path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("resolve(%q, %q) = %q, want error", tc.codebaseID, tc.path, full)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve(%q, %q) unexpected error: %v", tc.codebaseID, tc.path, err)
+			}
+			if !strings.HasPrefix(full, ls.baseDir) {
+				t.Fatalf("resolve(%q, %q) = %q, want it rooted under %q", tc.codebaseID, tc.path, full, ls.baseDir)
+			}
+		})
+	}
+}
+
+func TestLocalStoragePutGetRoundTrip(t *testing.T) {
+	ls := NewLocalStorage(t.TempDir())
+
+	if _, err := ls.Put("cb1", "nested/file.txt", strings.NewReader("hello world")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	reader, info, err := ls.Get("cb1", "nested/file.txt")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer reader.Close()
+
+	if info.Size != int64(len("hello world")) {
+		t.Errorf("Get returned size %d, want %d", info.Size, len("hello world"))
+	}
+}
+
+func TestLocalStorageGetMissingReturnsErrNotFound(t *testing.T) {
+	ls := NewLocalStorage(t.TempDir())
+
+	if _, _, err := ls.Get("cb1", "nope.txt"); err != ErrNotFound {
+		t.Fatalf("Get on missing file = %v, want ErrNotFound", err)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"archive/zip"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/google/uuid"
@@ -22,11 +25,16 @@ const (
 
 type StorageServer struct {
 	baseStorageDir string
+	storage        Storage
+	searchIndex    *SearchIndexer
+	avScanner      *AVScanner
+	manifestLocks  *manifestLocks
 }
 
 type StoreResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	Success       bool           `json:"success"`
+	Message       string         `json:"message"`
+	InfectedFiles []InfectedFile `json:"infected_files,omitempty"`
 }
 
 func NewStorageServer() *StorageServer {
@@ -39,11 +47,28 @@ func NewStorageServer() *StorageServer {
 		log.Fatalf("Failed to create base storage directory: %v", err)
 	}
 
+	storage := NewStorage(baseDir)
+
 	return &StorageServer{
 		baseStorageDir: baseDir,
+		storage:        storage,
+		searchIndex:    NewSearchIndexer(storage),
+		avScanner:      NewAVScanner(),
+		manifestLocks:  newManifestLocks(),
 	}
 }
 
+// reindexCodebase rebuilds the search index for a codebase in the
+// background so storeFiles/storeArchive can respond to the client without
+// waiting on the filesystem walk.
+func (s *StorageServer) reindexCodebase(codebaseID string) {
+	go func() {
+		if err := s.searchIndex.Reindex(codebaseID); err != nil {
+			log.Printf("Error reindexing codebase %s: %v", codebaseID, err)
+		}
+	}()
+}
+
 func (s *StorageServer) storeFiles(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadSize)
 
@@ -69,13 +94,9 @@ func (s *StorageServer) storeFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	storageDir := filepath.Join(s.baseStorageDir, codebaseID)
-	if err := os.MkdirAll(storageDir, 0755); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to create storage directory")
-		return
-	}
-
 	var storedFiles []string
+	var infectedFiles []InfectedFile
+	var metadataEntries []FileMetadata
 	var totalSize int64
 
 	for _, fileHeader := range files {
@@ -105,49 +126,76 @@ func (s *StorageServer) storeFiles(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		// Create the full path maintaining directory structure
-		fullPath := filepath.Join(storageDir, relativePath)
-
-		// Create all necessary parent directories
-		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-			log.Printf("Error creating directory for %s: %v", fullPath, err)
-			continue
+		var content io.Reader = file
+		if s.avScanner != nil {
+			buf, err := io.ReadAll(file)
+			if err != nil {
+				log.Printf("Error reading file %s for scanning: %v", relativePath, err)
+				continue
+			}
+
+			infected, signature, scanErr := s.avScanner.Scan(bytes.NewReader(buf))
+			if scanErr != nil {
+				log.Printf("AV scan error for %s: %v", relativePath, scanErr)
+				if !s.avScanner.AllowOnError() {
+					continue
+				}
+			} else if infected {
+				log.Printf("Rejected infected file %s: %s", relativePath, signature)
+				infectedFiles = append(infectedFiles, InfectedFile{Path: relativePath, Signature: signature})
+				continue
+			}
+
+			content = bytes.NewReader(buf)
 		}
 
-		// Create and write the file
-		dst, err := os.Create(fullPath)
-		if err != nil {
-			log.Printf("Error creating file %s: %v", fullPath, err)
-			continue
-		}
-		defer dst.Close()
+		hashedContent, sha256Hex, sniff := hashAndStore(content)
 
-		written, err := io.Copy(dst, file)
+		written, err := s.storage.Put(codebaseID, relativePath, hashedContent)
 		if err != nil {
-			log.Printf("Error writing file %s: %v", fullPath, err)
-			os.Remove(fullPath)
+			log.Printf("Error storing file %s: %v", relativePath, err)
 			continue
 		}
 
 		totalSize += written
 		storedFiles = append(storedFiles, relativePath)
+		metadataEntries = append(metadataEntries, FileMetadata{
+			RelativePath: relativePath,
+			Size:         written,
+			SHA256:       sha256Hex(),
+			Mime:         detectMime(relativePath),
+			Modified:     time.Now(),
+			IsText:       isTextFile(sniff.Bytes()),
+		})
 		log.Printf("Stored file: %s (%d bytes)", relativePath, written)
 	}
 
 	if len(storedFiles) == 0 {
-		os.RemoveAll(storageDir)
-		respondWithError(w, http.StatusBadRequest, "No valid files were stored")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":        false,
+			"error":          "No valid files were stored",
+			"infected_files": infectedFiles,
+		})
 		return
 	}
 
+	if err := s.updateManifest(codebaseID, metadataEntries); err != nil {
+		log.Printf("Error updating metadata sidecar for codebase %s: %v", codebaseID, err)
+	}
+
 	response := StoreResponse{
-		Success: true,
-		Message: fmt.Sprintf("Successfully stored %d files (%d bytes total)", len(storedFiles), totalSize),
+		Success:       true,
+		Message:       fmt.Sprintf("Successfully stored %d files (%d bytes total)", len(storedFiles), totalSize),
+		InfectedFiles: infectedFiles,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 
+	s.reindexCodebase(codebaseID)
+
 	log.Printf("Files stored for codebase %s: %d files, %d bytes", codebaseID, len(storedFiles), totalSize)
 }
 
@@ -174,30 +222,20 @@ func (s *StorageServer) getFileContent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Build the full path
-	baseDir := filepath.Join(s.baseStorageDir, codebaseID)
-	fullPath := filepath.Join(baseDir, cleanPath)
-
-	// Ensure the path is within the base directory
-	if !strings.HasPrefix(fullPath, baseDir) {
-		respondWithError(w, http.StatusBadRequest, "Invalid file path")
-		return
-	}
-
-	// Check if file exists
-	fileInfo, err := os.Stat(fullPath)
-	if os.IsNotExist(err) {
-		respondWithError(w, http.StatusNotFound, "File not found")
-		return
-	}
-
-	if fileInfo.IsDir() {
-		respondWithError(w, http.StatusBadRequest, "Cannot read directory as file")
+	// Open via the storage backend
+	reader, fileInfo, err := s.storage.Get(codebaseID, cleanPath)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "File not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to read file")
+		}
 		return
 	}
+	defer reader.Close()
 
 	// Read file content
-	content, err := os.ReadFile(fullPath)
+	content, err := io.ReadAll(reader)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to read file")
 		return
@@ -209,9 +247,9 @@ func (s *StorageServer) getFileContent(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"success":   true,
 		"file_path": cleanPath,
-		"size":      fileInfo.Size(),
+		"size":      fileInfo.Size,
 		"is_text":   isText,
-		"modified":  fileInfo.ModTime(),
+		"modified":  fileInfo.ModTime,
 	}
 
 	if isText {
@@ -247,52 +285,60 @@ func (s *StorageServer) downloadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Build the full path
-	baseDir := filepath.Join(s.baseStorageDir, codebaseID)
-	fullPath := filepath.Join(baseDir, cleanPath)
-
-	// Ensure the path is within the base directory
-	if !strings.HasPrefix(fullPath, baseDir) {
-		respondWithError(w, http.StatusBadRequest, "Invalid file path")
-		return
-	}
-
-	// Check if file exists
-	fileInfo, err := os.Stat(fullPath)
-	if os.IsNotExist(err) {
-		respondWithError(w, http.StatusNotFound, "File not found")
-		return
-	}
-
-	if fileInfo.IsDir() {
-		respondWithError(w, http.StatusBadRequest, "Cannot download directory")
-		return
-	}
-
-	// Open file for reading
-	file, err := os.Open(fullPath)
+	// Open via the storage backend
+	reader, fileInfo, err := s.storage.Get(codebaseID, cleanPath)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to open file")
+		if errors.Is(err, ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "File not found")
+		} else {
+			respondWithError(w, http.StatusInternalServerError, "Failed to open file")
+		}
 		return
 	}
-	defer file.Close()
+	defer reader.Close()
 
-	// Set headers for file download
+	// Set headers for file download. http.ServeContent fills in
+	// Accept-Ranges, Content-Length and handles conditional/range requests
+	// (If-Modified-Since, If-None-Match, Range) for us -- provided we give
+	// it something seekable.
 	filename := filepath.Base(cleanPath)
 	w.Header().Set("Content-Type", "application/octet-stream")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
-
-	// Stream file content
-	_, err = io.Copy(w, file)
-	if err != nil {
-		log.Printf("Error streaming file %s: %v", fullPath, err)
-		return
+	w.Header().Set("ETag", s.etagFor(codebaseID, cleanPath, fileInfo))
+
+	seeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		// Backend gave us a plain streaming reader (S3/GCS): buffer it so
+		// ServeContent can still honor Range and If-None-Match instead of
+		// silently downgrading to a non-resumable copy.
+		buf, err := io.ReadAll(reader)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to read file")
+			return
+		}
+		seeker = bytes.NewReader(buf)
 	}
+	http.ServeContent(w, r, filename, fileInfo.ModTime, seeker)
 
 	log.Printf("Downloaded file: %s from codebase %s", cleanPath, codebaseID)
 }
 
+// etagFor prefers the SHA-256 recorded in the codebase's metadata sidecar
+// (see metadata.go) so the ETag reflects actual content rather than a
+// timestamp; it falls back to a cheap size/mtime-derived tag for files
+// that predate the sidecar or belong to a backend that lacks one.
+func (s *StorageServer) etagFor(codebaseID, relativePath string, info FileInfo) string {
+	manifest, err := s.loadManifest(codebaseID)
+	if err == nil {
+		for _, f := range manifest.Files {
+			if f.RelativePath == relativePath && f.SHA256 != "" {
+				return fmt.Sprintf("%q", f.SHA256)
+			}
+		}
+	}
+	return fmt.Sprintf("%q", fmt.Sprintf("%x-%x", info.Size, info.ModTime.UnixNano()))
+}
+
 func (s *StorageServer) downloadZip(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	codebaseID := vars["id"]
@@ -302,10 +348,13 @@ func (s *StorageServer) downloadZip(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	storageDir := filepath.Join(s.baseStorageDir, codebaseID)
-
-	// Check if codebase directory exists
-	if _, err := os.Stat(storageDir); os.IsNotExist(err) {
+	// Check if codebase exists
+	exists, err := s.storage.Exists(codebaseID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to check codebase")
+		return
+	}
+	if !exists {
 		respondWithError(w, http.StatusNotFound, "Codebase not found")
 		return
 	}
@@ -316,8 +365,7 @@ func (s *StorageServer) downloadZip(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
 
 	// Create ZIP archive and stream it
-	err := createZipArchive(w, storageDir)
-	if err != nil {
+	if err := createZipArchive(w, s.storage, codebaseID); err != nil {
 		log.Printf("Error creating ZIP for codebase %s: %v", codebaseID, err)
 		return
 	}
@@ -325,43 +373,25 @@ func (s *StorageServer) downloadZip(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Downloaded ZIP archive for codebase: %s", codebaseID)
 }
 
-func createZipArchive(w io.Writer, sourceDir string) error {
+func createZipArchive(w io.Writer, storage Storage, codebaseID string) error {
 	zipWriter := zip.NewWriter(w)
 	defer zipWriter.Close()
 
-	return filepath.WalkDir(sourceDir, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Get relative path from source directory
-		relativePath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return err
-		}
-
-		// Skip the root directory itself
-		if relativePath == "." {
+	return storage.Walk(codebaseID, func(info FileInfo) error {
+		if info.RelPath == metadataSidecarName {
 			return nil
 		}
-
-		// Convert to forward slashes for ZIP compatibility
-		relativePath = strings.ReplaceAll(relativePath, "\\", "/")
-
-		if d.IsDir() {
-			// Create directory entry in ZIP
-			_, err := zipWriter.Create(relativePath + "/")
+		if info.IsDir {
+			_, err := zipWriter.Create(info.RelPath + "/")
 			return err
 		}
 
-		// Create file entry in ZIP
-		zipFile, err := zipWriter.Create(relativePath)
+		zipFile, err := zipWriter.Create(info.RelPath)
 		if err != nil {
 			return err
 		}
 
-		// Copy file content to ZIP
-		sourceFile, err := os.Open(path)
+		sourceFile, _, err := storage.Get(codebaseID, info.RelPath)
 		if err != nil {
 			return err
 		}
@@ -432,9 +462,14 @@ func main() {
 
 	// Storage routes
 	r.HandleFunc("/store", server.storeFiles).Methods("POST")
+	r.HandleFunc("/store-archive", server.storeArchive).Methods("POST")
 	r.HandleFunc("/content/{id}", server.getFileContent).Methods("GET")
 	r.HandleFunc("/download/{id}", server.downloadFile).Methods("GET")
 	r.HandleFunc("/zip/{id}", server.downloadZip).Methods("GET")
+	r.HandleFunc("/tar/{id}", server.downloadTarGz).Methods("GET")
+	r.HandleFunc("/search/{id}", server.searchCodebase).Methods("GET")
+	r.HandleFunc("/metadata/{id}", server.getMetadata).Methods("GET")
+	r.HandleFunc("/entry/{id}", server.getArchiveEntry).Methods("GET")
 
 	// Health check
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// metadataSidecarName is the manifest file written alongside a codebase's
+// files. It's excluded from search indexing and zip/tar archives since it's
+// bookkeeping, not codebase content; /download and /content still serve it
+// when it is requested by name.
+const metadataSidecarName = ".meta.json"
+
+// FileMetadata records everything storeFiles knows about one stored file
+// without having to re-stat or re-hash it later.
+type FileMetadata struct {
+	RelativePath string    `json:"relative_path"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	Mime         string    `json:"mime"`
+	Modified     time.Time `json:"modified"`
+	IsText       bool      `json:"is_text"`
+}
+
+// CodebaseManifest is the full metadata sidecar for one codebase.
+type CodebaseManifest struct {
+	CodebaseID string         `json:"codebase_id"`
+	Files      []FileMetadata `json:"files"`
+}
+
+// sniffWriter captures up to max bytes written through it, for content
+// sniffing (isTextFile only looks at the first 8KB anyway), while still
+// satisfying io.Writer for use inside an io.MultiWriter/TeeReader chain.
+type sniffWriter struct {
+	buf *bytes.Buffer
+	max int
+}
+
+func (w *sniffWriter) Write(p []byte) (int, error) {
+	if remaining := w.max - w.buf.Len(); remaining > 0 {
+		if remaining > len(p) {
+			remaining = len(p)
+		}
+		w.buf.Write(p[:remaining])
+	}
+	return len(p), nil
+}
+
+// hashAndStore wraps content so that, as it's copied into storage, we also
+// hash it (for the metadata sidecar) and sniff its first 8KB (to decide
+// is_text) without a second read of the file.
+func hashAndStore(content io.Reader) (reader io.Reader, hasher func() string, sniff *bytes.Buffer) {
+	h := sha256.New()
+	sniffBuf := &bytes.Buffer{}
+	tee := io.TeeReader(content, io.MultiWriter(h, &sniffWriter{buf: sniffBuf, max: 8192}))
+	return tee, func() string { return hex.EncodeToString(h.Sum(nil)) }, sniffBuf
+}
+
+// loadManifest reads a codebase's metadata sidecar, returning an empty
+// manifest (not an error) only when the sidecar genuinely doesn't exist yet.
+// Any other read failure (a transient backend error, a permission blip) is
+// propagated rather than silently treated as "no sidecar" -- updateManifest
+// would otherwise merge on top of an empty manifest and wipe every
+// previously recorded file's metadata.
+func (s *StorageServer) loadManifest(codebaseID string) (CodebaseManifest, error) {
+	reader, _, err := s.storage.Get(codebaseID, metadataSidecarName)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return CodebaseManifest{CodebaseID: codebaseID}, nil
+		}
+		return CodebaseManifest{}, fmt.Errorf("reading metadata sidecar: %w", err)
+	}
+	defer reader.Close()
+
+	var manifest CodebaseManifest
+	if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+		return CodebaseManifest{}, fmt.Errorf("decoding metadata sidecar: %w", err)
+	}
+	return manifest, nil
+}
+
+// manifestLocks serializes updateManifest's read-modify-write per codebase,
+// so two concurrent uploads to the same codebase can't race each other's
+// sidecar writes and silently drop one side's entries.
+type manifestLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newManifestLocks() *manifestLocks {
+	return &manifestLocks{locks: make(map[string]*sync.Mutex)}
+}
+
+// Lock acquires the per-codebase lock and returns a function to release it.
+func (m *manifestLocks) Lock(codebaseID string) func() {
+	m.mu.Lock()
+	l, ok := m.locks[codebaseID]
+	if !ok {
+		l = &sync.Mutex{}
+		m.locks[codebaseID] = l
+	}
+	m.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// updateManifest merges newEntries into the codebase's existing sidecar
+// (replacing any entry with the same path) and persists the result.
+func (s *StorageServer) updateManifest(codebaseID string, newEntries []FileMetadata) error {
+	unlock := s.manifestLocks.Lock(codebaseID)
+	defer unlock()
+
+	manifest, err := s.loadManifest(codebaseID)
+	if err != nil {
+		return err
+	}
+	manifest.CodebaseID = codebaseID
+
+	byPath := make(map[string]FileMetadata, len(manifest.Files))
+	for _, f := range manifest.Files {
+		byPath[f.RelativePath] = f
+	}
+	for _, f := range newEntries {
+		byPath[f.RelativePath] = f
+	}
+
+	manifest.Files = manifest.Files[:0]
+	for _, f := range byPath {
+		manifest.Files = append(manifest.Files, f)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = s.storage.Put(codebaseID, metadataSidecarName, bytes.NewReader(data))
+	return err
+}
+
+// detectMime derives a best-effort MIME type from the file's extension.
+func detectMime(relativePath string) string {
+	if t := mime.TypeByExtension(filepath.Ext(relativePath)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func (s *StorageServer) getMetadata(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	codebaseID := vars["id"]
+
+	if _, err := uuid.Parse(codebaseID); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid codebase ID")
+		return
+	}
+
+	manifest, err := s.loadManifest(codebaseID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to read metadata")
+		return
+	}
+	if len(manifest.Files) == 0 {
+		respondWithError(w, http.StatusNotFound, "No metadata found for codebase")
+		return
+	}
+
+	if file := r.URL.Query().Get("file"); file != "" {
+		cleanPath := filepath.Clean(file)
+		for _, f := range manifest.Files {
+			if f.RelativePath == cleanPath {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"success": true,
+					"file":    f,
+				})
+				return
+			}
+		}
+		respondWithError(w, http.StatusNotFound, "No metadata found for file")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"files":   manifest.Files,
+	})
+}
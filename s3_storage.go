@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// isS3NotFound translates the SDK's various "object doesn't exist" shapes
+// (a typed NoSuchKey/NotFound error, or a bare 404 response error from
+// HeadObject) into a single check.
+func isS3NotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+		return true
+	}
+	return false
+}
+
+// S3Storage stores codebases as objects under a configurable bucket and
+// key prefix, so that multiple server-B replicas can share one backing
+// store instead of each owning its own local disk.
+type S3Storage struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Storage builds an S3Storage from env vars:
+//
+//	S3_BUCKET   (required)
+//	S3_PREFIX   (optional, default "")
+//	AWS_REGION  (optional, falls back to the SDK's default config resolution)
+func NewS3Storage() (*S3Storage, error) {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is required when STORAGE_BACKEND=s3")
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		cfg.Region = region
+	}
+
+	return &S3Storage{
+		client: s3.NewFromConfig(cfg),
+		bucket: bucket,
+		prefix: strings.Trim(os.Getenv("S3_PREFIX"), "/"),
+	}, nil
+}
+
+func (s *S3Storage) key(codebaseID, path string) string {
+	key := codebaseID + "/" + strings.TrimPrefix(path, "/")
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	return key
+}
+
+func (s *S3Storage) Put(codebaseID, path string, r io.Reader) (int64, error) {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = s.client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(codebaseID, path)),
+		Body:   strings.NewReader(string(buf)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(buf)), nil
+}
+
+func (s *S3Storage) Get(codebaseID, path string) (io.ReadCloser, FileInfo, error) {
+	out, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(codebaseID, path)),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, FileInfo{}, ErrNotFound
+		}
+		return nil, FileInfo{}, err
+	}
+
+	info := FileInfo{RelPath: path}
+	if out.ContentLength != nil {
+		info.Size = *out.ContentLength
+	}
+	if out.LastModified != nil {
+		info.ModTime = *out.LastModified
+	}
+
+	return out.Body, info, nil
+}
+
+func (s *S3Storage) Stat(codebaseID, path string) (FileInfo, error) {
+	out, err := s.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(codebaseID, path)),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return FileInfo{}, ErrNotFound
+		}
+		return FileInfo{}, err
+	}
+
+	info := FileInfo{RelPath: path}
+	if out.ContentLength != nil {
+		info.Size = *out.ContentLength
+	}
+	if out.LastModified != nil {
+		info.ModTime = *out.LastModified
+	}
+	return info, nil
+}
+
+func (s *S3Storage) Walk(codebaseID string, fn WalkFn) error {
+	prefix := s.key(codebaseID, "")
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range page.Contents {
+			relPath := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+			if relPath == "" {
+				continue
+			}
+
+			info := FileInfo{RelPath: relPath}
+			if obj.Size != nil {
+				info.Size = *obj.Size
+			}
+			if obj.LastModified != nil {
+				info.ModTime = *obj.LastModified
+			}
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *S3Storage) Delete(codebaseID, path string) error {
+	_, err := s.client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(codebaseID, path)),
+	})
+	return err
+}
+
+func (s *S3Storage) Exists(codebaseID string) (bool, error) {
+	found := false
+	err := s.Walk(codebaseID, func(FileInfo) error {
+		found = true
+		return nil
+	})
+	return found, err
+}
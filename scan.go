@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/dutchcoders/go-clamd"
+)
+
+// InfectedFile describes an upload that was rejected by the AV scan.
+type InfectedFile struct {
+	Path      string `json:"path"`
+	Signature string `json:"signature"`
+}
+
+// AVScanner streams a file through a ClamAV daemon and reports whether it
+// is infected.
+type AVScanner struct {
+	client   *clamd.Clamd
+	failOpen bool
+}
+
+// NewAVScanner builds an AVScanner from env vars, or returns nil if
+// scanning is disabled via SKIP_SCAN=1:
+//
+//	CLAMAV_HOST      (default "localhost")
+//	CLAMAV_PORT      (default "3310")
+//	CLAMAV_FAIL_OPEN ("1" to allow uploads through when clamd is
+//	                  unreachable; defaults to fail-closed)
+func NewAVScanner() *AVScanner {
+	if os.Getenv("SKIP_SCAN") == "1" {
+		log.Printf("AV scanning disabled via SKIP_SCAN=1")
+		return nil
+	}
+
+	host := os.Getenv("CLAMAV_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port := os.Getenv("CLAMAV_PORT")
+	if port == "" {
+		port = "3310"
+	}
+
+	return &AVScanner{
+		client:   clamd.NewClamd(fmt.Sprintf("tcp://%s:%s", host, port)),
+		failOpen: os.Getenv("CLAMAV_FAIL_OPEN") == "1",
+	}
+}
+
+// Scan streams r through clamd's INSTREAM command, returning the infecting
+// signature name when a match is found.
+func (sc *AVScanner) Scan(r io.Reader) (infected bool, signature string, err error) {
+	abort := make(chan bool)
+	defer close(abort)
+
+	resultCh, err := sc.client.ScanStream(r, abort)
+	if err != nil {
+		return false, "", fmt.Errorf("connecting to clamd: %w", err)
+	}
+
+	result := <-resultCh
+	if result == nil {
+		return false, "", fmt.Errorf("no response from clamd")
+	}
+
+	if result.Status == clamd.RES_FOUND {
+		return true, result.Description, nil
+	}
+	return false, "", nil
+}
+
+// AllowOnError reports whether an upload should proceed when the daemon
+// itself couldn't be reached, per the CLAMAV_FAIL_OPEN toggle.
+func (sc *AVScanner) AllowOnError() bool {
+	return sc.failOpen
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// maxIndexedFileSize skips content indexing for files larger than this, so
+// a handful of huge binaries can't blow up memory or indexing time.
+const maxIndexedFileSize = 10 << 20
+
+// codebaseIndex is the in-memory per-codebase cache: file paths for name
+// search, plus cached text lines per file so content search doesn't have to
+// re-read storage on every query.
+type codebaseIndex struct {
+	files []string            // all file paths, for name search
+	lines map[string][]string // path -> text lines, cached for content search
+}
+
+// SearchIndexer maintains one codebaseIndex per codebase, rebuilt whenever
+// storeFiles completes for that codebase. Reads and writes are guarded by
+// an RWMutex so lookups never block on an in-flight rebuild for an
+// unrelated codebase... and never race a rebuild of the same one.
+type SearchIndexer struct {
+	mu      sync.RWMutex
+	indexes map[string]*codebaseIndex
+	storage Storage
+}
+
+func NewSearchIndexer(storage Storage) *SearchIndexer {
+	return &SearchIndexer{
+		storage: storage,
+		indexes: make(map[string]*codebaseIndex),
+	}
+}
+
+// Reindex walks the codebase via the Storage backend and rebuilds its
+// index from scratch, so it works the same whether files live on local
+// disk or in S3/GCS. storeFiles/storeArchive kick this off via
+// reindexCodebase in a background goroutine *after* they've already
+// responded to the client, so there's a window right after a 200 where
+// the index is still the old one (or doesn't exist yet, on a codebase's
+// first upload) and /search/{id} can 404 with "codebase not indexed" or
+// return stale results. There's no signal today for when a reindex has
+// finished; a client that needs up-to-date results should poll /search
+// and tolerate a transient 404/stale read after storing.
+func (si *SearchIndexer) Reindex(codebaseID string) error {
+	idx := &codebaseIndex{
+		lines: make(map[string][]string),
+	}
+
+	err := si.storage.Walk(codebaseID, func(info FileInfo) error {
+		if info.IsDir || info.RelPath == metadataSidecarName {
+			return nil
+		}
+		idx.files = append(idx.files, info.RelPath)
+
+		if info.Size > maxIndexedFileSize {
+			return nil
+		}
+
+		reader, _, err := si.storage.Get(codebaseID, info.RelPath)
+		if err != nil {
+			log.Printf("Search index: skipping unreadable file %s: %v", info.RelPath, err)
+			return nil
+		}
+		defer reader.Close()
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			log.Printf("Search index: skipping unreadable file %s: %v", info.RelPath, err)
+			return nil
+		}
+		if !isTextFile(content) {
+			return nil
+		}
+
+		idx.lines[info.RelPath] = strings.Split(string(content), "\n")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	si.mu.Lock()
+	si.indexes[codebaseID] = idx
+	si.mu.Unlock()
+
+	return nil
+}
+
+// Invalidate drops a codebase's index, e.g. because it no longer exists.
+func (si *SearchIndexer) Invalidate(codebaseID string) {
+	si.mu.Lock()
+	delete(si.indexes, codebaseID)
+	si.mu.Unlock()
+}
+
+// searchMatch is one hit from Search. Offset is the byte index of the
+// match within Line's text; it's only meaningful for content matches (it's
+// left at its zero value, not omitted, for name matches, whereas Line is
+// omitted there).
+type searchMatch struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Offset  int    `json:"offset"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// Search looks up matches for query within the given codebase's index.
+// searchType is "name" (default) or "content"; when useRegex is true, the
+// query is compiled as a regular expression instead of matched as a plain
+// substring.
+func (si *SearchIndexer) Search(codebaseID, query, searchType string, useRegex bool) ([]searchMatch, error) {
+	si.mu.RLock()
+	idx, ok := si.indexes[codebaseID]
+	si.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("codebase not indexed")
+	}
+
+	// matcher reports whether a line matches; locate finds where the first
+	// match starts within it, so snippetAround and the reported offset
+	// agree with each other in both plain and regex mode.
+	var matcher func(string) bool
+	var locate func(string) int
+	if useRegex {
+		re, err := regexp.Compile(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		matcher = re.MatchString
+		locate = func(s string) int {
+			loc := re.FindStringIndex(s)
+			if loc == nil {
+				return 0
+			}
+			return loc[0]
+		}
+	} else {
+		matcher = func(s string) bool { return strings.Contains(s, query) }
+		locate = func(s string) int {
+			if idx := strings.Index(s, query); idx >= 0 {
+				return idx
+			}
+			return 0
+		}
+	}
+
+	var results []searchMatch
+
+	if searchType == "content" {
+		for _, path := range idx.files {
+			lines, ok := idx.lines[path]
+			if !ok {
+				continue
+			}
+			for i, line := range lines {
+				if !matcher(line) {
+					continue
+				}
+				offset := locate(line)
+				results = append(results, searchMatch{
+					Path:    path,
+					Line:    i + 1,
+					Offset:  offset,
+					Snippet: snippetAround(line, offset),
+				})
+			}
+		}
+		return results, nil
+	}
+
+	// Default: search by file name.
+	for _, path := range idx.files {
+		if matcher(filepath.Base(path)) || matcher(path) {
+			results = append(results, searchMatch{Path: path})
+		}
+	}
+	return results, nil
+}
+
+// snippetAround trims a matching line down to a short window centered on
+// the match at byte offset idx, for display in search results.
+func snippetAround(line string, idx int) string {
+	const window = 40
+
+	start := idx - window
+	if start < 0 {
+		start = 0
+	}
+	end := idx + window
+	if end > len(line) {
+		end = len(line)
+	}
+
+	snippet := strings.TrimSpace(line[start:end])
+	if start > 0 {
+		snippet = "..." + snippet
+	}
+	if end < len(line) {
+		snippet = snippet + "..."
+	}
+	return snippet
+}
+
+func (s *StorageServer) searchCodebase(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	codebaseID := vars["id"]
+
+	if _, err := uuid.Parse(codebaseID); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid codebase ID")
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		respondWithError(w, http.StatusBadRequest, "Query parameter 'q' is required")
+		return
+	}
+
+	searchType := r.URL.Query().Get("type")
+	if searchType == "" {
+		searchType = "name"
+	}
+	if searchType != "name" && searchType != "content" {
+		respondWithError(w, http.StatusBadRequest, "type must be 'name' or 'content'")
+		return
+	}
+
+	useRegex, _ := strconv.ParseBool(r.URL.Query().Get("regex"))
+
+	matches, err := s.searchIndex.Search(codebaseID, query, searchType, useRegex)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(bw).Encode(map[string]interface{}{
+		"success": true,
+		"query":   query,
+		"type":    searchType,
+		"matches": matches,
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// ErrNotFound is returned by Get/Stat when path doesn't exist within
+// codebaseID. Every backend must translate its own not-found error (a
+// missing file on disk, an S3 "NoSuchKey", a GCS ErrObjectNotExist) to
+// this sentinel so callers can branch on one thing regardless of backend.
+var ErrNotFound = errors.New("storage: not found")
+
+// FileInfo is a backend-agnostic stand-in for os.FileInfo, since S3/GCS
+// objects don't have one.
+type FileInfo struct {
+	RelPath string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// WalkFn is called once per entry during a Storage.Walk, in the same
+// spirit as filepath.WalkDir's callback.
+type WalkFn func(info FileInfo) error
+
+// Storage abstracts the persistence layer used by storeFiles,
+// getFileContent, downloadFile and createZipArchive, so the same handler
+// code can run against the local filesystem or an object store.
+//
+// Get's returned io.ReadCloser may additionally implement io.Seeker (the
+// local backend's does, since it hands back an *os.File); downloadFile
+// type-asserts for it and, when it is missing, buffers the whole content in
+// memory so range requests still work.
+//
+// Get and Stat return ErrNotFound (wrapped is fine) when path doesn't
+// exist, regardless of backend.
+type Storage interface {
+	// Put writes r to path within codebaseID, returning the number of
+	// bytes written.
+	Put(codebaseID, path string, r io.Reader) (int64, error)
+	// Get opens path within codebaseID for reading. Returns ErrNotFound
+	// if path doesn't exist.
+	Get(codebaseID, path string) (io.ReadCloser, FileInfo, error)
+	// Stat returns metadata for path within codebaseID without opening
+	// it. Returns ErrNotFound if path doesn't exist.
+	Stat(codebaseID, path string) (FileInfo, error)
+	// Walk visits every entry stored under codebaseID. The local backend
+	// also reports directories (with IsDir set); object-store backends
+	// report only objects.
+	Walk(codebaseID string, fn WalkFn) error
+	// Delete removes path within codebaseID.
+	Delete(codebaseID, path string) error
+	// Exists reports whether codebaseID is present: the local backend
+	// checks that its directory exists (even if empty), object-store
+	// backends that at least one object is stored under it.
+	Exists(codebaseID string) (bool, error)
+}
+
+// NewStorage selects a Storage implementation based on the
+// STORAGE_BACKEND env var ("local", "s3" or "gcs"), defaulting to local
+// disk storage rooted at baseDir.
+func NewStorage(baseDir string) Storage {
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "s3":
+		backend, err := NewS3Storage()
+		if err != nil {
+			log.Fatalf("Failed to initialize S3 storage backend: %v", err)
+		}
+		return backend
+	case "gcs":
+		backend, err := NewGCSStorage()
+		if err != nil {
+			log.Fatalf("Failed to initialize GCS storage backend: %v", err)
+		}
+		return backend
+	default:
+		return NewLocalStorage(baseDir)
+	}
+}